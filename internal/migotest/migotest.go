@@ -0,0 +1,18 @@
+// Package migotest holds helpers shared by the test suites of migo's
+// internal packages, so devirt, deadstmt and migoutil don't each keep
+// their own copy.
+package migotest
+
+import (
+	"go/token"
+
+	"github.com/jujuyuki/migo"
+)
+
+// FuncNamed returns a Function with body, registered into prog.
+func FuncNamed(prog *migo.Program, name string, body ...migo.Statement) *migo.Function {
+	f := migo.NewFunction(name, token.Position{})
+	f.AddStmts(body...)
+	prog.AddFunction(f)
+	return f
+}
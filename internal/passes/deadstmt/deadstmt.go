@@ -0,0 +1,161 @@
+// Package deadstmt removes statements inside a Function body that can
+// never be reached, complementing deadcall's removal of calls to
+// functions that no longer exist.
+package deadstmt
+
+import "github.com/jujuyuki/migo"
+
+// Remove walks every Function.Stmts in prog, including nested
+// IfStatement, IfForStatement and SelectStatement bodies, and deletes
+// statements that cannot be reached:
+//
+//   - statements following a call to a function whose body is a single
+//     close followed by a recv on the same channel, which never returns;
+//   - the leading run of statements an IfStatement/IfForStatement's
+//     branches share, which is hoisted out in front of the if; the if
+//     keeps the rest of each branch, and is dropped only when both
+//     branches are identical and so left empty; and
+//   - SelectStatement cases whose sole action is tau, when at least one
+//     other case is not tau-only.
+//
+// It reports whether it changed prog, so callers can drive a fixpoint:
+// removing a dead branch can empty out a function that feeds back into
+// taufunc.Find.
+func Remove(prog *migo.Program) bool {
+	return RemoveFuncs(prog, prog.Funcs)
+}
+
+// RemoveFuncs behaves like Remove but only considers the Functions in
+// funcs, leaving the rest of prog untouched. Callers that have
+// partitioned prog's call graph into independent components, e.g. the
+// strongly connected components from Program.AllSCCs, can run it over
+// each partition concurrently: every lookup reads prog but RemoveFuncs
+// never writes to a Function outside of funcs.
+func RemoveFuncs(prog *migo.Program, funcs []*migo.Function) bool {
+	changed := false
+	for _, f := range funcs {
+		// before must be rendered ahead of removeStmts: removeStmts
+		// descends into If/IfForStatement branches by mutating their
+		// Then/Else fields in place, through the same *IfStatement/
+		// *IfForStatement/*SelectStatement pointers f.Stmts already
+		// holds, so comparing f.Stmts against the result afterwards
+		// would compare already-mutated statements to themselves.
+		before := migo.RenderStmts(f.Stmts)
+		next := removeStmts(prog, f.Stmts)
+		if !migo.EqualRendered(before, next) {
+			changed = true
+		}
+		f.Stmts = next
+	}
+	return changed
+}
+
+// removeStmts rebuilds stmts via migo.RewriteStmts, which handles
+// descending into the nested statement lists of any IfStatement,
+// IfForStatement or SelectStatement in stmts; the callback below only
+// needs to decide what to do with the (already-rebuilt) statements at
+// this level.
+func removeStmts(prog *migo.Program, stmts []migo.Statement) []migo.Statement {
+	return migo.RewriteStmts(stmts, func(stmts []migo.Statement) []migo.Statement {
+		out := make([]migo.Statement, 0, len(stmts))
+		for _, stmt := range stmts {
+			switch s := stmt.(type) {
+			case *migo.IfStatement:
+				if prefix := commonPrefixLen(s.Then, s.Else); prefix > 0 {
+					out = append(out, s.Then[:prefix]...)
+					s.Then = s.Then[prefix:]
+					s.Else = s.Else[prefix:]
+					if len(s.Then) == 0 && len(s.Else) == 0 {
+						continue
+					}
+				}
+			case *migo.IfForStatement:
+				if prefix := commonPrefixLen(s.Then, s.Else); prefix > 0 {
+					out = append(out, s.Then[:prefix]...)
+					s.Then = s.Then[prefix:]
+					s.Else = s.Else[prefix:]
+					if len(s.Then) == 0 && len(s.Else) == 0 {
+						continue
+					}
+				}
+			case *migo.SelectStatement:
+				s.Cases = pruneTauCases(s.Cases)
+			}
+			out = append(out, stmt)
+			if call, ok := stmt.(*migo.CallStatement); ok && alwaysBlocks(prog, call.Name) {
+				break
+			}
+		}
+		return out
+	})
+}
+
+// pruneTauCases removes cases whose sole action is tau, provided at
+// least one case in cases is not tau-only; a select with nothing but tau
+// cases still has to offer one. Each case's own statements have already
+// been rebuilt by RewriteStmts by the time pruneTauCases sees them.
+func pruneTauCases(cases [][]migo.Statement) [][]migo.Statement {
+	hasNonTau := false
+	for _, c := range cases {
+		if !isTauOnly(c) {
+			hasNonTau = true
+			break
+		}
+	}
+	if !hasNonTau {
+		return cases
+	}
+
+	kept := cases[:0]
+	for _, c := range cases {
+		if !isTauOnly(c) {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+func isTauOnly(stmts []migo.Statement) bool {
+	if len(stmts) != 1 {
+		return false
+	}
+	_, ok := stmts[0].(*migo.TauStatement)
+	return ok
+}
+
+// alwaysBlocks reports whether name is a Function in prog whose entire
+// body is a close followed by a recv on the same channel, which this
+// pass treats as never returning.
+func alwaysBlocks(prog *migo.Program, name string) bool {
+	fn, ok := prog.Function(name)
+	if !ok || len(fn.Stmts) != 2 {
+		return false
+	}
+	cl, ok := fn.Stmts[0].(*migo.CloseStatement)
+	if !ok {
+		return false
+	}
+	rv, ok := fn.Stmts[1].(*migo.RecvStatement)
+	if !ok {
+		return false
+	}
+	return cl.Chan == rv.Chan
+}
+
+// commonPrefixLen reports how many leading statements a and b share,
+// comparing statement by statement via String(). IfStatement/
+// IfForStatement branches that are fully identical are just the case
+// where the prefix covers both branches in their entirety.
+func commonPrefixLen(a, b []migo.Statement) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		if a[i].String() != b[i].String() {
+			return i
+		}
+	}
+	return n
+}
+
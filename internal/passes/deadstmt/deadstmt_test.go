@@ -0,0 +1,185 @@
+package deadstmt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jujuyuki/migo"
+	"github.com/jujuyuki/migo/internal/migotest"
+)
+
+// stmtsString renders stmts the way Function.String renders a function
+// body, so expectations can be written against the same text Parse would
+// read back.
+func stmtsString(stmts []migo.Statement) string {
+	var b strings.Builder
+	for _, s := range stmts {
+		b.WriteString(s.String())
+		b.WriteString(";\n")
+	}
+	return b.String()
+}
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name        string
+		build       func() (prog *migo.Program, f *migo.Function)
+		wantChanged bool
+		wantStmts   string
+	}{
+		{
+			name: "statements after a call that always blocks are dropped",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				migotest.FuncNamed(prog, "blocks",
+					&migo.CloseStatement{Chan: "c"},
+					&migo.RecvStatement{Chan: "c"},
+				)
+				f := migotest.FuncNamed(prog, "f",
+					&migo.CallStatement{Name: "blocks"},
+					&migo.SendStatement{Chan: "d"},
+				)
+				return prog, f
+			},
+			wantChanged: true,
+			wantStmts:   "call blocks();\n",
+		},
+		{
+			name: "IfStatement branches identical to their sibling collapse",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				f := migotest.FuncNamed(prog, "f", &migo.IfStatement{
+					Then: []migo.Statement{&migo.SendStatement{Chan: "c"}},
+					Else: []migo.Statement{&migo.SendStatement{Chan: "c"}},
+				})
+				return prog, f
+			},
+			wantChanged: true,
+			wantStmts:   "send c;\n",
+		},
+		{
+			name: "IfStatement branches sharing a leading prefix hoist it out, keeping the rest",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				f := migotest.FuncNamed(prog, "f", &migo.IfStatement{
+					Then: []migo.Statement{
+						&migo.SendStatement{Chan: "a"},
+						&migo.SendStatement{Chan: "b"},
+						&migo.SendStatement{Chan: "c"},
+					},
+					Else: []migo.Statement{
+						&migo.SendStatement{Chan: "a"},
+						&migo.SendStatement{Chan: "b"},
+						&migo.SendStatement{Chan: "d"},
+					},
+				})
+				return prog, f
+			},
+			wantChanged: true,
+			wantStmts:   "send a;\nsend b;\nif send c; else send d; endif;\n",
+		},
+		{
+			name: "IfForStatement branches identical to their sibling collapse, dropping ForCond",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				f := migotest.FuncNamed(prog, "f", &migo.IfForStatement{
+					ForCond: "i<n",
+					Then:    []migo.Statement{&migo.TauStatement{}},
+					Else:    []migo.Statement{&migo.TauStatement{}},
+				})
+				return prog, f
+			},
+			wantChanged: true,
+			wantStmts:   "tau;\n",
+		},
+		{
+			name: "IfForStatement branches sharing a leading prefix hoist it out, keeping ForCond on the rest",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				f := migotest.FuncNamed(prog, "f", &migo.IfForStatement{
+					ForCond: "i<n",
+					Then: []migo.Statement{
+						&migo.SendStatement{Chan: "a"},
+						&migo.SendStatement{Chan: "c"},
+					},
+					Else: []migo.Statement{
+						&migo.SendStatement{Chan: "a"},
+						&migo.SendStatement{Chan: "d"},
+					},
+				})
+				return prog, f
+			},
+			wantChanged: true,
+			wantStmts:   "send a;\nifFor (int i<n) then send c; else send d; endif;\n",
+		},
+		{
+			name: "IfForStatement branches that differ are left alone",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				f := migotest.FuncNamed(prog, "f", &migo.IfForStatement{
+					ForCond: "i<n",
+					Then:    []migo.Statement{&migo.SendStatement{Chan: "c"}},
+					Else:    []migo.Statement{&migo.SendStatement{Chan: "d"}},
+				})
+				return prog, f
+			},
+			wantChanged: false,
+			wantStmts:   "ifFor (int i<n) then send c; else send d; endif;\n",
+		},
+		{
+			name: "tau-only select cases are pruned when another case is not tau-only",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				f := migotest.FuncNamed(prog, "f", &migo.SelectStatement{Cases: [][]migo.Statement{
+					{&migo.SendStatement{Chan: "c"}},
+					{&migo.TauStatement{}},
+				}})
+				return prog, f
+			},
+			wantChanged: true,
+			wantStmts:   "select\n      case send c;\n    endselect;\n",
+		},
+		{
+			name: "a select with nothing but tau cases is left alone",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				f := migotest.FuncNamed(prog, "f", &migo.SelectStatement{Cases: [][]migo.Statement{
+					{&migo.TauStatement{}},
+					{&migo.TauStatement{}},
+				}})
+				return prog, f
+			},
+			wantChanged: false,
+			wantStmts:   "select\n      case tau;\n      case tau;\n    endselect;\n",
+		},
+		{
+			name: "a tau-only select case nested inside an IfStatement's Then is pruned",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				f := migotest.FuncNamed(prog, "f", &migo.IfStatement{
+					Then: []migo.Statement{&migo.SelectStatement{Cases: [][]migo.Statement{
+						{&migo.SendStatement{Chan: "c"}},
+						{&migo.TauStatement{}},
+					}}},
+					Else: []migo.Statement{&migo.SendStatement{Chan: "d"}},
+				})
+				return prog, f
+			},
+			wantChanged: true,
+			wantStmts:   "if select\n      case send c;\n    endselect; else send d; endif;\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, f := tt.build()
+			got := Remove(prog)
+			if got != tt.wantChanged {
+				t.Errorf("Remove() changed = %v, want %v", got, tt.wantChanged)
+			}
+			if got, want := stmtsString(f.Stmts), tt.wantStmts; got != want {
+				t.Errorf("f.Stmts = %q, want %q", got, want)
+			}
+		})
+	}
+}
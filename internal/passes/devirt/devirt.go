@@ -0,0 +1,169 @@
+// Package devirt rewrites interface-typed call and spawn targets into
+// direct calls where the concrete implementation can be determined
+// statically.
+package devirt
+
+import (
+	"strings"
+
+	"github.com/jujuyuki/migo"
+)
+
+// Devirtualize walks prog and rewrites every CallStatement and
+// SpawnStatement whose Name is a virtual dispatch, such as the
+// "(iface).M" names the SSA extractor produces for an interface method
+// call, into a direct call to the concrete implementation.
+//
+// Candidate implementations are Functions in prog whose Name shares the
+// dispatched method's suffix (e.g. ".M"), optionally narrowed by a type
+// set a caller registered with Program.RegisterInterfaceImpls. When
+// exactly one candidate matches, the statement is rewritten in place to
+// call it directly. When several match, the statement is replaced by a
+// SelectStatement with one case per candidate, so the result remains a
+// sound over-approximation of the original dispatch. When none match,
+// the statement is left untouched so a later deadcall.Remove can prune
+// it.
+//
+// It reports whether it changed prog, so callers can drive a fixpoint:
+// resolving one dispatch can turn a previously-unresolved callee of a
+// different Function into a resolvable one.
+func Devirtualize(prog *migo.Program) bool {
+	return DevirtualizeFuncs(prog, prog.Funcs)
+}
+
+// DevirtualizeFuncs behaves like Devirtualize but only rewrites the
+// Functions in funcs, leaving the rest of prog untouched. Callers that
+// have partitioned prog's call graph into independent components, e.g.
+// the strongly connected components from Program.AllSCCs, can run it
+// over each partition concurrently: every candidate lookup reads prog
+// but DevirtualizeFuncs never writes to a Function outside of funcs.
+func DevirtualizeFuncs(prog *migo.Program, funcs []*migo.Function) bool {
+	changed := false
+	for _, f := range funcs {
+		// before must be rendered ahead of devirtStmts: devirtStmts
+		// rewrites CallStatement/SpawnStatement.Name and select-ifies
+		// statements in place, through the same backing array and
+		// pointers f.Stmts already holds, so comparing f.Stmts against
+		// the result afterwards would compare already-mutated
+		// statements to themselves.
+		before := migo.RenderStmts(f.Stmts)
+		next := devirtStmts(prog, f.Stmts)
+		if !migo.EqualRendered(before, next) {
+			changed = true
+		}
+		f.Stmts = next
+	}
+	return changed
+}
+
+// devirtStmts rewrites every CallStatement and SpawnStatement in stmts,
+// and in the nested statement lists of any IfStatement, IfForStatement or
+// SelectStatement within it, via migo.RewriteStmts.
+func devirtStmts(prog *migo.Program, stmts []migo.Statement) []migo.Statement {
+	return migo.RewriteStmts(stmts, func(stmts []migo.Statement) []migo.Statement {
+		for i, stmt := range stmts {
+			switch s := stmt.(type) {
+			case *migo.CallStatement:
+				stmts[i] = devirtCall(prog, s)
+			case *migo.SpawnStatement:
+				stmts[i] = devirtSpawn(prog, s)
+			}
+		}
+		return stmts
+	})
+}
+
+func devirtCall(prog *migo.Program, s *migo.CallStatement) migo.Statement {
+	switch targets := implsOf(prog, s.Name); len(targets) {
+	case 0:
+		return s
+	case 1:
+		s.Name = targets[0]
+		return s
+	default:
+		cases := make([][]migo.Statement, len(targets))
+		for i, t := range targets {
+			cases[i] = []migo.Statement{&migo.CallStatement{Name: t, Params: s.Params, Pos: s.Pos}}
+		}
+		return &migo.SelectStatement{Cases: cases, Pos: s.Pos}
+	}
+}
+
+func devirtSpawn(prog *migo.Program, s *migo.SpawnStatement) migo.Statement {
+	switch targets := implsOf(prog, s.Name); len(targets) {
+	case 0:
+		return s
+	case 1:
+		s.Name = targets[0]
+		return s
+	default:
+		cases := make([][]migo.Statement, len(targets))
+		for i, t := range targets {
+			cases[i] = []migo.Statement{&migo.SpawnStatement{Name: t, Params: s.Params, Pos: s.Pos}}
+		}
+		return &migo.SelectStatement{Cases: cases, Pos: s.Pos}
+	}
+}
+
+// implsOf returns the concrete Function names that could be the actual
+// target of a virtual dispatch to name, or nil if name is not recognised
+// as one.
+func implsOf(prog *migo.Program, name string) []string {
+	if _, ok := prog.Function(name); ok {
+		// name already resolves to a real Function in prog: it is an
+		// ordinary, already-resolvable direct call, not a virtual
+		// dispatch placeholder - a genuine placeholder from the SSA
+		// extractor never collides with a defined Function's name.
+		// Every migo method name happens to look like "(iface).M" too,
+		// so this check, not the shape splitVirtual requires, is what
+		// actually distinguishes the two.
+		return nil
+	}
+	iface, method, ok := splitVirtual(name)
+	if !ok {
+		return nil
+	}
+	if registered, ok := prog.InterfaceImpls(iface); ok {
+		var found []string
+		for _, impl := range registered {
+			if !strings.HasSuffix(impl, "."+method) {
+				continue
+			}
+			if _, ok := prog.Function(impl); ok {
+				found = append(found, impl)
+			}
+		}
+		if len(found) > 0 {
+			return found
+		}
+	}
+	return bySuffix(prog, method)
+}
+
+// splitVirtual splits a dispatch name such as "(iface).M" into its
+// interface part ("iface") and method part ("M").
+func splitVirtual(name string) (iface, method string, ok bool) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return "", "", false
+	}
+	method = name[i+1:]
+	if method == "" {
+		return "", "", false
+	}
+	iface = strings.Trim(name[:i], "()")
+	return iface, method, true
+}
+
+// bySuffix finds every Function in prog whose Name ends with the given
+// method, e.g. "(*T).M" for method "M".
+func bySuffix(prog *migo.Program, method string) []string {
+	suffix := "." + method
+	var found []string
+	for _, f := range prog.Funcs {
+		if strings.HasSuffix(f.Name, suffix) {
+			found = append(found, f.Name)
+		}
+	}
+	return found
+}
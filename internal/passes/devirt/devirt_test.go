@@ -0,0 +1,92 @@
+package devirt
+
+import (
+	"testing"
+
+	"github.com/jujuyuki/migo"
+	"github.com/jujuyuki/migo/internal/migotest"
+)
+
+func TestDevirtualize(t *testing.T) {
+	tests := []struct {
+		name        string
+		build       func() (prog *migo.Program, caller *migo.Function)
+		wantChanged bool
+		wantStmt    string
+	}{
+		{
+			name: "single candidate rewrites to a direct call",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				caller := migotest.FuncNamed(prog, "caller", &migo.CallStatement{Name: "(iface).M"})
+				migotest.FuncNamed(prog, "(*T).M", &migo.TauStatement{})
+				return prog, caller
+			},
+			wantChanged: true,
+			wantStmt:    "call `(*T).M`()",
+		},
+		{
+			name: "multiple candidates rewrite to a select",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				caller := migotest.FuncNamed(prog, "caller", &migo.SpawnStatement{Name: "(iface).M"})
+				migotest.FuncNamed(prog, "(*T).M", &migo.TauStatement{})
+				migotest.FuncNamed(prog, "(*U).M", &migo.TauStatement{})
+				return prog, caller
+			},
+			wantChanged: true,
+			wantStmt:    "select\n      case spawn `(*T).M`();\n      case spawn `(*U).M`();\n    endselect",
+		},
+		{
+			name: "no candidate leaves the statement untouched for deadcall",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				caller := migotest.FuncNamed(prog, "caller", &migo.CallStatement{Name: "(iface).Missing"})
+				return prog, caller
+			},
+			wantChanged: false,
+			wantStmt:    "call `(iface).Missing`()",
+		},
+		{
+			name: "a name that already resolves directly is never rewritten",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				caller := migotest.FuncNamed(prog, "caller", &migo.CallStatement{Name: "(*T).Foo"})
+				migotest.FuncNamed(prog, "(*T).Foo", &migo.TauStatement{})
+				// An unrelated type sharing the method name must not
+				// make devirt treat the direct call above as a
+				// dispatch to resolve among the two.
+				migotest.FuncNamed(prog, "(*U).Foo", &migo.TauStatement{})
+				return prog, caller
+			},
+			wantChanged: false,
+			wantStmt:    "call `(*T).Foo`()",
+		},
+		{
+			name: "registered impls narrow the candidates before falling back to suffix matching",
+			build: func() (*migo.Program, *migo.Function) {
+				prog := migo.NewProgram()
+				caller := migotest.FuncNamed(prog, "caller", &migo.CallStatement{Name: "(iface).M"})
+				migotest.FuncNamed(prog, "(*T).M", &migo.TauStatement{})
+				migotest.FuncNamed(prog, "(*U).M", &migo.TauStatement{})
+				prog.RegisterInterfaceImpls("iface", []string{"(*T).M"})
+				return prog, caller
+			},
+			wantChanged: true,
+			wantStmt:    "call `(*T).M`()",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, caller := tt.build()
+			got := Devirtualize(prog)
+			if got != tt.wantChanged {
+				t.Errorf("Devirtualize() changed = %v, want %v", got, tt.wantChanged)
+			}
+			if stmt := caller.Stmts[0].String(); stmt != tt.wantStmt {
+				t.Errorf("caller.Stmts[0] = %q, want %q", stmt, tt.wantStmt)
+			}
+		})
+	}
+}
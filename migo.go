@@ -6,6 +6,7 @@ import (
 	"go/token"
 	"log"
 	"strings"
+	"sync"
 )
 
 var (
@@ -13,6 +14,43 @@ var (
 	noPosition = token.Position{Line: 0}
 )
 
+// quoteName renders name the way Statement/Function text output refers
+// to it. Most SSA-derived names (including ones already containing
+// literal '"' or '.') are safe to print as-is; a name containing a
+// character the grammar gives syntactic meaning, such as the parens of
+// an interface method's receiver in "(*T).M", is backtick-quoted
+// instead, since migo names never contain a backtick. This keeps Parse
+// able to read back exactly the Name/Chan value String wrote, instead
+// of the lossy, unparseable mangling nameFilter.Replace used to produce.
+//
+// This is an intentional, breaking change to the text Program/Function/
+// Statement.String emit: names that previously had '(', ')', '*', '"'
+// and '-' silently stripped (e.g. "def main.main():") are now either
+// printed verbatim or backtick-quoted (e.g. `def "main".main():`).
+// External tooling that parses migo text against the old, lossy format
+// needs to be updated to accept quoted names; SimpleName/nameFilter
+// remain available on Function/CallStatement/SpawnStatement for callers
+// that still want the old filtered form for display purposes.
+func quoteName(name string) string {
+	if needsQuoting(name) {
+		return "`" + name + "`"
+	}
+	return name
+}
+
+// needsQuoting reports whether name contains a byte quoteName's
+// backtick-quoting must guard against: either the parser's own
+// statement-delimiting punctuation, or the backtick used to quote.
+func needsQuoting(name string) bool {
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case ' ', '\t', '\n', '\r', '(', ')', ',', ':', ';', '=', '`':
+			return true
+		}
+	}
+	return false
+}
+
 // NamedVar is a named variable.
 type NamedVar interface {
 	Name() string
@@ -23,6 +61,12 @@ type NamedVar interface {
 type Program struct {
 	Funcs   []*Function // Function definitions.
 	visited map[*Function]int
+
+	ifaceImpls map[string][]string // Interface -> registered concrete implementations.
+
+	funcIndexOnce sync.Once
+	funcIndexMu   sync.RWMutex
+	funcIndex     map[string]*Function // Name -> Function, built lazily by Function.
 }
 
 // NewProgram creates a new empty Program.
@@ -34,64 +78,128 @@ func NewProgram() *Program {
 //
 // If Function already exists this does nothing.
 func (p *Program) AddFunction(f *Function) {
-	for _, fun := range p.Funcs {
-		if fun.Name == f.Name {
-			return
-		}
+	if _, ok := p.Function(f.Name); ok {
+		return
 	}
 	p.Funcs = append(p.Funcs, f)
+	p.buildFuncIndex()
+	p.funcIndexMu.Lock()
+	p.funcIndex[f.Name] = f
+	p.funcIndexMu.Unlock()
 }
 
 // Function gets a Function in a Program by name.
 //
 // Returns the function and a bool indicating whether lookup was successful.
+//
+// Function is safe to call concurrently, including the very first call
+// that builds funcIndex: callers that process a Program's call graph
+// over independent goroutines, such as migoutil.SimplifyProgram running
+// passes over strongly connected components, don't need to arrange a
+// single-threaded warm-up call first.
 func (p *Program) Function(name string) (*Function, bool) {
-	for _, f := range p.Funcs {
-		if f.Name == name {
-			return f, true
+	p.buildFuncIndex()
+	p.funcIndexMu.RLock()
+	defer p.funcIndexMu.RUnlock()
+	f, ok := p.funcIndex[name]
+	return f, ok
+}
+
+// buildFuncIndex builds funcIndex from Funcs the first time it's
+// called, and is a no-op on every call after that; sync.Once makes this
+// safe under concurrent callers, unlike a bare nil check on the map.
+func (p *Program) buildFuncIndex() {
+	p.funcIndexOnce.Do(func() {
+		p.funcIndexMu.Lock()
+		defer p.funcIndexMu.Unlock()
+		p.funcIndex = make(map[string]*Function, len(p.Funcs))
+		for _, f := range p.Funcs {
+			p.funcIndex[f.Name] = f
 		}
+	})
+}
+
+// RegisterInterfaceImpls records impls as the known concrete
+// implementations of the interface iface, named by the interface part
+// of a dispatch name (e.g. "iface" for "(iface).M").
+//
+// Callers with static type information unavailable from the migo program
+// text alone, such as the SSA extractor using x/tools/go/ssa, use this to
+// narrow the candidates a devirtualization pass considers for a given
+// interface dispatch.
+func (p *Program) RegisterInterfaceImpls(iface string, impls []string) {
+	if p.ifaceImpls == nil {
+		p.ifaceImpls = make(map[string][]string)
 	}
-	return nil, false
+	p.ifaceImpls[iface] = append(p.ifaceImpls[iface], impls...)
+}
+
+// InterfaceImpls returns the concrete implementations registered for
+// iface by RegisterInterfaceImpls, and whether any were registered.
+func (p *Program) InterfaceImpls(iface string) ([]string, bool) {
+	impls, ok := p.ifaceImpls[iface]
+	return impls, ok
 }
 
-// findEmptyFuncMain marks functions empty if they do not have communication.
+// findEmptyFuncMain marks functions empty if they do not have
+// communication, propagating from the call graph reachable from f.
+//
+// Reachability is computed as an SCC-level fixpoint: the functions
+// reachable from f are grouped into strongly connected components via
+// SCCs, processed bottom-up, and within each component HasComm is OR'd
+// across its members until it stops changing. A plain recursive walk
+// does not converge correctly when functions call each other in a
+// cycle, since whichever function is visited first sees its mutual
+// callers as still-unknown.
 func (p *Program) findEmptyFuncMain(f *Function) {
-	known := make(map[string]bool)
-	p.findEmptyFunc(f, known)
+	for _, scc := range p.SCCs(f) {
+		propagateHasComm(p, scc)
+	}
 	f.HasComm = true
 }
 
-// findEmptyFunc marks functions empty if they do not have communication.
-// takes a map of known functions in parameter.
-func (p *Program) findEmptyFunc(f *Function, known map[string]bool) {
-	if _, ok := known[f.Name]; ok {
-		return
+// propagateHasComm OR's HasComm across every Function in scc until it
+// reaches a fixpoint. scc is processed after all of the components its
+// members call into, so those callees' HasComm values are already
+// final; only edges internal to scc can still change anything.
+func propagateHasComm(p *Program, scc []*Function) {
+	for {
+		changed := false
+		for _, f := range scc {
+			if !f.HasComm && callsComm(p, f) {
+				f.HasComm = true
+				changed = true
+			}
+		}
+		if !changed {
+			return
+		}
 	}
-	known[f.Name] = f.HasComm
-	for _, stmt := range f.Stmts {
-		switch stmt := stmt.(type) {
+}
+
+// callsComm reports whether f calls or spawns, anywhere in its body, a
+// Function whose HasComm is already known to be true.
+func callsComm(p *Program, f *Function) bool {
+	found := false
+	Inspect(f, func(n interface{}) bool {
+		if found {
+			return false
+		}
+		var callee string
+		switch s := n.(type) {
 		case *CallStatement:
-			if child, ok := p.Function(stmt.Name); ok {
-				if hasComm, ok := known[child.Name]; ok {
-					f.HasComm = f.HasComm || hasComm
-				} else {
-					p.findEmptyFunc(child, known)
-					f.HasComm = f.HasComm || child.HasComm
-				}
-				known[f.Name] = f.HasComm
-			}
+			callee = s.Name
 		case *SpawnStatement:
-			if child, ok := p.Function(stmt.Name); ok {
-				if hasComm, ok := known[child.Name]; ok {
-					f.HasComm = f.HasComm || hasComm
-				} else {
-					p.findEmptyFunc(child, known)
-					f.HasComm = f.HasComm || child.HasComm
-				}
-				known[f.Name] = f.HasComm
-			}
+			callee = s.Name
+		default:
+			return true
 		}
-	}
+		if child, ok := p.Function(callee); ok && child.HasComm {
+			found = true
+		}
+		return !found
+	})
+	return found
 }
 
 func (p *Program) String() string {
@@ -104,7 +212,7 @@ func (p *Program) String() string {
 	return buf.String()
 }
 
-func (p Program) PrintWithProperties(props Properties) string {
+func (p *Program) PrintWithProperties(props Properties) string {
 	var buf bytes.Buffer
 	main, _ := p.Function("\"main\".main")
 
@@ -238,16 +346,26 @@ func (f *Function) AddStmts(stmts ...Statement) {
 func hasComm(stmts []Statement) bool {
 	for _, s := range stmts {
 		switch s := s.(type) {
-		case *SendStatement, *RecvStatement, *CloseStatement, *SelectStatement, *NewChanStatement:
+		case *SendStatement, *RecvStatement, *CloseStatement, *SelectStatement, *NewChanStatement,
+			*SyncWGAdd, *SyncWGDone, *SyncWGWait, *SyncOnceDo,
+			*SyncCondWait, *SyncCondSignal, *SyncCondBroadcast:
 			return true
 		case *IfStatement:
-			return hasComm(s.Then) || hasComm(s.Else)
+			if hasComm(s.Then) || hasComm(s.Else) {
+				return true
+			}
 		case *IfForStatement:
-			return hasComm(s.Then) || hasComm(s.Else)
+			if hasComm(s.Then) || hasComm(s.Else) {
+				return true
+			}
 		case *CallStatement:
-			return len(s.Params) > 0
+			if len(s.Params) > 0 {
+				return true
+			}
 		case *SpawnStatement:
-			return len(s.Params) > 0
+			if len(s.Params) > 0 {
+				return true
+			}
 		}
 	}
 	return false
@@ -268,7 +386,7 @@ func (f *Function) Restore() ([]Statement, error) { return f.stack.Pop() }
 func (f *Function) String() string {
 	var buf bytes.Buffer
 	buf.WriteString(fmt.Sprintf("def %s(%s):\n",
-		f.SimpleName(), CalleeParameterString(f.Params)))
+		quoteName(f.Name), CalleeParameterString(f.Params)))
 	if len(f.Stmts) == 0 {
 		f.AddStmts(&TauStatement{})
 	}
@@ -284,7 +402,7 @@ func (f *Function) PrintWithProperties(props Properties) string {
 		buf.WriteString(fmt.Sprintf("%s\n", prop))
 	}
 	buf.WriteString(fmt.Sprintf("def %s(%s):\n",
-		f.SimpleName(), CalleeParameterString(f.Params)))
+		quoteName(f.Name), CalleeParameterString(f.Params)))
 	if len(f.Stmts) == 0 {
 		f.AddStmts(&TauStatement{})
 	}
@@ -317,7 +435,7 @@ func (s *CallStatement) SimpleName() string {
 
 func (s *CallStatement) String() string {
 	return fmt.Sprintf("call %s(%s)",
-		s.SimpleName(), CallerParameterString(s.Params))
+		quoteName(s.Name), CallerParameterString(s.Params))
 }
 
 // AddParams add parameter(s) to a Function call.
@@ -346,7 +464,7 @@ type CloseStatement struct {
 }
 
 func (s *CloseStatement) String() string {
-	return fmt.Sprintf("close %s", s.Chan)
+	return fmt.Sprintf("close %s", quoteName(s.Chan))
 }
 
 func (s *CloseStatement) Position() token.Position {
@@ -367,7 +485,7 @@ func (s *SpawnStatement) SimpleName() string {
 
 func (s *SpawnStatement) String() string {
 	return fmt.Sprintf("spawn %s(%s)",
-		s.SimpleName(), CallerParameterString(s.Params))
+		quoteName(s.Name), CallerParameterString(s.Params))
 }
 
 // AddParams add parameter(s) to a goroutine spawning Function call.
@@ -399,7 +517,7 @@ type NewChanStatement struct {
 
 func (s *NewChanStatement) String() string {
 	return fmt.Sprintf("let %s = newchan %s, %d",
-		s.Name.Name(), nameFilter.Replace(s.Chan), s.Size)
+		s.Name.Name(), quoteName(s.Chan), s.Size)
 }
 
 func (s *NewChanStatement) Position() token.Position {
@@ -500,7 +618,7 @@ type SendStatement struct {
 }
 
 func (s *SendStatement) String() string {
-	return fmt.Sprintf("send %s", s.Chan)
+	return fmt.Sprintf("send %s", quoteName(s.Chan))
 }
 
 func (s *SendStatement) Position() token.Position {
@@ -514,7 +632,7 @@ type RecvStatement struct {
 }
 
 func (s *RecvStatement) String() string {
-	return fmt.Sprintf("recv %s", s.Chan)
+	return fmt.Sprintf("recv %s", quoteName(s.Chan))
 }
 
 func (s *RecvStatement) Position() token.Position {
@@ -542,7 +660,7 @@ type MemRead struct {
 }
 
 func (s *MemRead) String() string {
-	return fmt.Sprintf("read %s", nameFilter.Replace(s.Name))
+	return fmt.Sprintf("read %s", quoteName(s.Name))
 }
 
 func (s *MemRead) Position() token.Position {
@@ -556,7 +674,7 @@ type MemWrite struct {
 }
 
 func (s *MemWrite) String() string {
-	return fmt.Sprintf("write %s", nameFilter.Replace(s.Name))
+	return fmt.Sprintf("write %s", quoteName(s.Name))
 }
 
 func (s *MemWrite) Position() token.Position {
@@ -586,7 +704,7 @@ type SyncMutexLock struct {
 }
 
 func (m *SyncMutexLock) String() string {
-	return fmt.Sprintf("lock %s", nameFilter.Replace(m.Name))
+	return fmt.Sprintf("lock %s", quoteName(m.Name))
 }
 
 func (s *SyncMutexLock) Position() token.Position {
@@ -600,7 +718,7 @@ type SyncMutexUnlock struct {
 }
 
 func (m *SyncMutexUnlock) String() string {
-	return fmt.Sprintf("unlock %s", nameFilter.Replace(m.Name))
+	return fmt.Sprintf("unlock %s", quoteName(m.Name))
 }
 
 func (s *SyncMutexUnlock) Position() token.Position {
@@ -630,7 +748,7 @@ type SyncRWMutexRLock struct {
 }
 
 func (m *SyncRWMutexRLock) String() string {
-	return fmt.Sprintf("rlock %s", nameFilter.Replace(m.Name))
+	return fmt.Sprintf("rlock %s", quoteName(m.Name))
 }
 
 func (s *SyncRWMutexRLock) Position() token.Position {
@@ -644,13 +762,162 @@ type SyncRWMutexRUnlock struct {
 }
 
 func (m *SyncRWMutexRUnlock) String() string {
-	return fmt.Sprintf("runlock %s", nameFilter.Replace(m.Name))
+	return fmt.Sprintf("runlock %s", quoteName(m.Name))
 }
 
 func (s *SyncRWMutexRUnlock) Position() token.Position {
 	return s.Pos
 }
 
+// WaitGroup primitives
+
+// NewSyncWaitGroup is a sync.WaitGroup initialisation statement.
+type NewSyncWaitGroup struct {
+	Name NamedVar
+	Pos  token.Position
+}
+
+func (s *NewSyncWaitGroup) String() string {
+	return fmt.Sprintf("letsync %s waitgroup", s.Name.Name())
+}
+
+func (s *NewSyncWaitGroup) Position() token.Position {
+	return s.Pos
+}
+
+// SyncWGAdd is a sync.WaitGroup Add statement.
+type SyncWGAdd struct {
+	Name  string
+	Delta int
+	Pos   token.Position
+}
+
+func (s *SyncWGAdd) String() string {
+	return fmt.Sprintf("wgadd %s, %d", quoteName(s.Name), s.Delta)
+}
+
+func (s *SyncWGAdd) Position() token.Position {
+	return s.Pos
+}
+
+// SyncWGDone is a sync.WaitGroup Done statement.
+type SyncWGDone struct {
+	Name string
+	Pos  token.Position
+}
+
+func (s *SyncWGDone) String() string {
+	return fmt.Sprintf("wgdone %s", quoteName(s.Name))
+}
+
+func (s *SyncWGDone) Position() token.Position {
+	return s.Pos
+}
+
+// SyncWGWait is a sync.WaitGroup Wait statement.
+type SyncWGWait struct {
+	Name string
+	Pos  token.Position
+}
+
+func (s *SyncWGWait) String() string {
+	return fmt.Sprintf("wgwait %s", quoteName(s.Name))
+}
+
+func (s *SyncWGWait) Position() token.Position {
+	return s.Pos
+}
+
+// Once primitives
+
+// NewSyncOnce is a sync.Once initialisation statement.
+type NewSyncOnce struct {
+	Name NamedVar
+	Pos  token.Position
+}
+
+func (s *NewSyncOnce) String() string {
+	return fmt.Sprintf("letsync %s once", s.Name.Name())
+}
+
+func (s *NewSyncOnce) Position() token.Position {
+	return s.Pos
+}
+
+// SyncOnceDo is a sync.Once Do statement, calling the function named Fn
+// through the Once named Name.
+type SyncOnceDo struct {
+	Name string
+	Fn   string
+	Pos  token.Position
+}
+
+func (s *SyncOnceDo) String() string {
+	return fmt.Sprintf("oncedo %s %s", quoteName(s.Name), quoteName(s.Fn))
+}
+
+func (s *SyncOnceDo) Position() token.Position {
+	return s.Pos
+}
+
+// Cond primitives
+
+// NewSyncCond is a sync.Cond initialisation statement.
+type NewSyncCond struct {
+	Name NamedVar
+	Pos  token.Position
+}
+
+func (s *NewSyncCond) String() string {
+	return fmt.Sprintf("letsync %s cond", s.Name.Name())
+}
+
+func (s *NewSyncCond) Position() token.Position {
+	return s.Pos
+}
+
+// SyncCondWait is a sync.Cond Wait statement.
+type SyncCondWait struct {
+	Name string
+	Pos  token.Position
+}
+
+func (s *SyncCondWait) String() string {
+	return fmt.Sprintf("condwait %s", quoteName(s.Name))
+}
+
+func (s *SyncCondWait) Position() token.Position {
+	return s.Pos
+}
+
+// SyncCondSignal is a sync.Cond Signal statement.
+type SyncCondSignal struct {
+	Name string
+	Pos  token.Position
+}
+
+func (s *SyncCondSignal) String() string {
+	return fmt.Sprintf("condsignal %s", quoteName(s.Name))
+}
+
+func (s *SyncCondSignal) Position() token.Position {
+	return s.Pos
+}
+
+// SyncCondBroadcast is a sync.Cond Broadcast statement.
+type SyncCondBroadcast struct {
+	Name string
+	Pos  token.Position
+}
+
+func (s *SyncCondBroadcast) String() string {
+	return fmt.Sprintf("condbroadcast %s", quoteName(s.Name))
+}
+
+func (s *SyncCondBroadcast) Position() token.Position {
+	return s.Pos
+}
+
 // Maps source code line number to property comments
 type Properties map[int][]string
 
@@ -0,0 +1,16 @@
+package migo
+
+import "testing"
+
+// TestAddStmtsHasCommScansWholeSlice guards against hasComm stopping at
+// the first statement it recognises: a leading CallStatement with no
+// Params must not hide a later communication statement such as
+// SyncWGWait from AddStmts' HasComm bookkeeping.
+func TestAddStmtsHasCommScansWholeSlice(t *testing.T) {
+	f := NewFunction("f", noPosition)
+	f.AddStmts(&CallStatement{Name: "blocks"}, &SyncWGWait{Name: "wg"})
+
+	if !f.HasComm {
+		t.Errorf("HasComm = false, want true: SyncWGWait after a non-communicating CallStatement must still be seen")
+	}
+}
@@ -1,10 +1,16 @@
 package migoutil
 
 import (
-	"github.com/jujuyuki/migo/internal/passes/unused"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
 	"github.com/jujuyuki/migo"
 	"github.com/jujuyuki/migo/internal/passes/deadcall"
+	"github.com/jujuyuki/migo/internal/passes/deadstmt"
+	"github.com/jujuyuki/migo/internal/passes/devirt"
 	"github.com/jujuyuki/migo/internal/passes/taufunc"
+	"github.com/jujuyuki/migo/internal/passes/unused"
 )
 
 // SimplifyProgram takes the input Program prog and reduce it
@@ -12,13 +18,153 @@ import (
 //
 // It removes functions that reduces to τ, and
 // removes call to functions that do not exist.
+//
+// taufunc/devirt/deadcall/deadstmt run to a fixpoint: removing a dead
+// branch can empty out a function's body, which in turn lets taufunc
+// reduce its callers to τ. devirt runs before deadcall.Remove, so a
+// still-unresolved virtual-dispatch placeholder survives long enough for
+// devirt to get a chance at it instead of being pruned as a call to a
+// function that doesn't exist; deadstmt runs after, so it sees the
+// calls deadcall just pruned. deadcall.Remove reports no changed flag of
+// its own, so its effect is detected by comparing prog's total statement
+// count before and after the call - cheap relative to serializing the
+// whole program twice, and valid because deadcall only ever deletes
+// statements, never rewrites one in place - and a round where it prunes
+// a call devirt left behind still needs to feed back into the next
+// iteration's taufunc/unused pass.
+//
+// Within each iteration, devirt and deadstmt - the two passes that
+// rewrite a single Function in isolation, using only the target names
+// already on its CallStatement/SpawnStatement nodes - each run over
+// prog's independent strongly connected components concurrently, using
+// a worker pool sized to GOMAXPROCS. taufunc and unused stay whole-
+// program, sequential calls: both answer a question about the entire
+// call graph (taufunc propagates "reduces to τ" from callees up through
+// every caller transitively; unused asks whether a Function is reachable
+// from main at all) rather than rewriting one Function using only local
+// information, so they cannot be partitioned across SCCs the same way
+// without changing those packages' own internals.
 func SimplifyProgram(prog *migo.Program) *migo.Program {
-	if mainmain, hasMM := prog.Function(`"main".main`); hasMM {
-		taufunc.Find(prog, taufunc.RemoveExcept(mainmain))
-		unused.Remove(prog, mainmain)
-	} else {
-		taufunc.Find(prog, taufunc.Remove)
+	for {
+		if mainmain, hasMM := prog.Function(`"main".main`); hasMM {
+			taufunc.Find(prog, taufunc.RemoveExcept(mainmain))
+			unused.Remove(prog, mainmain)
+		} else {
+			taufunc.Find(prog, taufunc.Remove)
+		}
+		devirtChanged := runOverSCCs(prog, devirt.DevirtualizeFuncs)
+		before := countStmts(prog)
+		deadcall.Remove(prog)
+		deadcallChanged := countStmts(prog) != before
+		deadstmtChanged := runOverSCCs(prog, deadstmt.RemoveFuncs)
+		if !devirtChanged && !deadcallChanged && !deadstmtChanged {
+			break
+		}
 	}
-	deadcall.Remove(prog)
 	return prog
 }
+
+// countStmts counts every statement in prog, including ones nested
+// inside IfStatement/IfForStatement/SelectStatement bodies, without
+// paying for String() formatting the way comparing prog.String() would.
+func countStmts(prog *migo.Program) int {
+	n := 0
+	migo.Inspect(prog, func(node interface{}) bool {
+		if _, ok := node.(migo.Statement); ok {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// runOverSCCs runs pass over every strongly connected component of
+// prog's call graph, processing components with no call edges between
+// them concurrently, and reports whether pass changed anything.
+func runOverSCCs(prog *migo.Program, pass func(*migo.Program, []*migo.Function) bool) bool {
+	var changed int32
+	for _, batch := range independentBatches(prog) {
+		tasks := make([]func(), len(batch))
+		for i, scc := range batch {
+			scc := scc
+			tasks[i] = func() {
+				if pass(prog, scc) {
+					atomic.StoreInt32(&changed, 1)
+				}
+			}
+		}
+		runParallel(tasks)
+	}
+	return changed != 0
+}
+
+// independentBatches groups prog's strongly connected components,
+// bottom-up, into batches with no call edges between components of the
+// same batch, so every component in a batch is safe to process
+// concurrently with the others.
+func independentBatches(prog *migo.Program) [][][]*migo.Function {
+	sccs := prog.AllSCCs()
+
+	sccOf := make(map[string]int, len(prog.Funcs))
+	for i, scc := range sccs {
+		for _, f := range scc {
+			sccOf[f.Name] = i
+		}
+	}
+
+	level := make([]int, len(sccs))
+	maxLevel := 0
+	for i, scc := range sccs {
+		for _, f := range scc {
+			migo.Inspect(f, func(n interface{}) bool {
+				var callee string
+				switch s := n.(type) {
+				case *migo.CallStatement:
+					callee = s.Name
+				case *migo.SpawnStatement:
+					callee = s.Name
+				default:
+					return true
+				}
+				// sccs is bottom-up, so any callee in a
+				// different component already has its level
+				// computed.
+				if j, ok := sccOf[callee]; ok && j != i && level[j]+1 > level[i] {
+					level[i] = level[j] + 1
+				}
+				return true
+			})
+		}
+		if level[i] > maxLevel {
+			maxLevel = level[i]
+		}
+	}
+
+	batches := make([][][]*migo.Function, maxLevel+1)
+	for i, scc := range sccs {
+		batches[level[i]] = append(batches[level[i]], scc)
+	}
+	return batches
+}
+
+// runParallel runs every fn concurrently, at most runtime.GOMAXPROCS(0)
+// at a time, and waits for all of them to finish.
+func runParallel(fns []func()) {
+	limit := runtime.GOMAXPROCS(0)
+	if limit < 1 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for _, fn := range fns {
+		fn := fn
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,71 @@
+package migoutil
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jujuyuki/migo"
+	"github.com/jujuyuki/migo/internal/migotest"
+)
+
+// TestSimplifyProgram runs the full pass pipeline - taufunc, devirt,
+// deadcall, deadstmt, iterated to a fixpoint - over a small program
+// exercising every pass at once: an interface dispatch devirt can
+// resolve to a single implementation, a dead branch deadstmt can
+// collapse, and a call to an undefined function for deadcall to prune.
+// It is run with -race in CI because runOverSCCs processes independent
+// SCCs concurrently.
+func TestSimplifyProgram(t *testing.T) {
+	prog := migo.NewProgram()
+	main := migotest.FuncNamed(prog, `"main".main`,
+		&migo.CallStatement{Name: "(iface).M"},
+		&migo.CallStatement{Name: "missing"},
+		&migo.IfStatement{
+			Then: []migo.Statement{&migo.SendStatement{Chan: "c"}},
+			Else: []migo.Statement{&migo.SendStatement{Chan: "c"}},
+		},
+	)
+	migotest.FuncNamed(prog, "(*T).M", &migo.TauStatement{})
+
+	SimplifyProgram(prog)
+
+	got := main.String()
+	if strings.Contains(got, "missing") {
+		t.Errorf("main.String() = %q, still calls the undefined function \"missing\"", got)
+	}
+	if !strings.Contains(got, "(*T).M") {
+		t.Errorf("main.String() = %q, want the devirtualized call to (*T).M", got)
+	}
+	if strings.Count(got, "send c") != 1 {
+		t.Errorf("main.String() = %q, want the duplicate if/else branch collapsed to one send", got)
+	}
+}
+
+// buildChain returns a Program of n functions, each calling the next, so
+// independentBatches has to walk a single SCC per level.
+func buildChain(n int) *migo.Program {
+	prog := migo.NewProgram()
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("f%d", i)
+		if i == n-1 {
+			migotest.FuncNamed(prog, name, &migo.TauStatement{})
+			continue
+		}
+		migotest.FuncNamed(prog, name, &migo.CallStatement{Name: fmt.Sprintf("f%d", i+1)})
+	}
+	return prog
+}
+
+func BenchmarkSimplifyProgram(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("funcs=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				prog := buildChain(n)
+				b.StartTimer()
+				SimplifyProgram(prog)
+			}
+		})
+	}
+}
@@ -0,0 +1,622 @@
+package migo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// ident is a minimal NamedVar used to reconstruct variable references when
+// parsing, since the migo text syntax only ever carries their name.
+type ident string
+
+func (i ident) Name() string   { return string(i) }
+func (i ident) String() string { return string(i) }
+
+// Parse reads a Program written in migo syntax, the same syntax emitted by
+// Program.String, from r and reconstructs the corresponding *Program.
+func Parse(r io.Reader) (*Program, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: string(data)}
+	prog := NewProgram()
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		f, err := p.parseFunction()
+		if err != nil {
+			return nil, err
+		}
+		prog.AddFunction(f)
+	}
+	resolveCalleeParams(prog)
+	return prog, nil
+}
+
+// resolveCalleeParams fixes up the Callee side of every call/spawn's
+// Params once every def in the program has been parsed: the text syntax
+// only ever carries a parameter's caller-side name at the call site
+// ("call f(a, b)") and its callee-side name at the def site
+// ("def f(p, q):"), so parseCallLike has no way to know a parameter's
+// Callee when it parses the call itself. Resolve it here by matching
+// position against the target Function's own declared Params.
+func resolveCalleeParams(prog *Program) {
+	Inspect(prog, func(n interface{}) bool {
+		switch s := n.(type) {
+		case *CallStatement:
+			resolveParams(prog, s.Name, s.Params)
+		case *SpawnStatement:
+			resolveParams(prog, s.Name, s.Params)
+		}
+		return true
+	})
+}
+
+// resolveParams sets each of params[i].Callee to the i-th Param the
+// Function named name declares, leaving the caller-side name in place
+// when name has no matching Function (e.g. a call to a function that
+// does not exist, left for deadcall.Remove to prune) or declares fewer
+// parameters than the call site supplies.
+func resolveParams(prog *Program, name string, params []*Parameter) {
+	target, ok := prog.Function(name)
+	if !ok {
+		return
+	}
+	for i, param := range params {
+		if i < len(target.Params) {
+			param.Callee = target.Params[i].Callee
+		}
+	}
+}
+
+// ParseFile reads and parses the migo program in the file named by path.
+func ParseFile(path string) (*Program, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// parser is a hand-rolled recursive-descent reader for the tiny migo text
+// syntax. The grammar has no need for a separate lexing pass: tokens are
+// either a punctuation byte or a maximal run of non-punctuation,
+// non-space bytes (word), so parser reads directly off src.
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) eof() bool {
+	p.skipSpace()
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+			continue
+		}
+		break
+	}
+}
+
+// isWordByte reports whether c can appear inside a word (identifier,
+// keyword, or number). The migo syntax only needs punctuation for
+// '(', ')', ',', ':', ';' and '=', so everything else, including '.',
+// '_' and the quotes left in SSA names, belongs to the word.
+func isWordByte(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '(', ')', ',', ':', ';', '=':
+		return false
+	}
+	return true
+}
+
+func (p *parser) word() (string, error) {
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == '`' {
+		return p.quotedWord()
+	}
+	start := p.pos
+	for p.pos < len(p.src) && isWordByte(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("expected a word")
+	}
+	return p.src[start:p.pos], nil
+}
+
+// quotedWord reads a backtick-quoted name, the escape quoteName uses for a
+// name containing a byte that would otherwise be read as punctuation. The
+// name ends at the first backtick after the opening one, so a name that
+// itself contains a backtick is not read back intact. The opening
+// backtick has not been consumed yet.
+func (p *parser) quotedWord() (string, error) {
+	start := p.pos
+	p.pos++ // opening backtick
+	for p.pos < len(p.src) && p.src[p.pos] != '`' {
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		return "", p.errorf("unterminated quoted name starting at offset %d", start)
+	}
+	name := p.src[start+1 : p.pos]
+	p.pos++ // closing backtick
+	return name, nil
+}
+
+// peekWord returns the next word without consuming it.
+func (p *parser) peekWord() (string, error) {
+	save := p.pos
+	w, err := p.word()
+	p.pos = save
+	return w, err
+}
+
+func (p *parser) expectWord(w string) error {
+	got, err := p.word()
+	if err != nil {
+		return err
+	}
+	if got != w {
+		return p.errorf("expected %q, got %q", w, got)
+	}
+	return nil
+}
+
+// peekByte returns the next non-space byte without consuming it, or 0 at
+// EOF.
+func (p *parser) peekByte() byte {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) expectByte(c byte) error {
+	if got := p.peekByte(); got != c {
+		return p.errorf("expected %q, got %q", string(c), string(got))
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("migo: parse error at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseFunction() (*Function, error) {
+	if err := p.expectWord("def"); err != nil {
+		return nil, err
+	}
+	name, err := p.word()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectByte('('); err != nil {
+		return nil, err
+	}
+	params, err := p.parseWordList()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectByte(')'); err != nil {
+		return nil, err
+	}
+	if err := p.expectByte(':'); err != nil {
+		return nil, err
+	}
+
+	f := NewFunction(name, noPosition)
+	for _, n := range params {
+		v := ident(n)
+		f.AddParams(&Parameter{Caller: v, Callee: v})
+	}
+
+	for {
+		if p.eof() {
+			break
+		}
+		if w, _ := p.peekWord(); w == "def" {
+			break
+		}
+		stmt, err := p.parseStmt()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectByte(';'); err != nil {
+			return nil, err
+		}
+		f.AddStmts(stmt)
+	}
+	return f, nil
+}
+
+// parseWordList parses a comma-separated list of words, stopping at the
+// next ')'. It is used for both function parameter lists and call/spawn
+// argument lists.
+func (p *parser) parseWordList() ([]string, error) {
+	var words []string
+	if p.peekByte() == ')' {
+		return words, nil
+	}
+	for {
+		w, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+		if p.peekByte() != ',' {
+			break
+		}
+		p.pos++
+	}
+	return words, nil
+}
+
+// parseStmtsUntil parses a sequence of "stmt;"-terminated statements up to
+// (not including) the next occurrence of one of stop.
+func (p *parser) parseStmtsUntil(stop ...string) ([]Statement, error) {
+	var stmts []Statement
+	for {
+		w, err := p.peekWord()
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range stop {
+			if w == s {
+				return stmts, nil
+			}
+		}
+		stmt, err := p.parseStmt()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectByte(';'); err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+}
+
+func (p *parser) parseStmt() (Statement, error) {
+	kw, err := p.peekWord()
+	if err != nil {
+		return nil, err
+	}
+	switch kw {
+	case "let":
+		return p.parseNewChan()
+	case "send":
+		p.word()
+		ch, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &SendStatement{Chan: ch}, nil
+	case "recv":
+		p.word()
+		ch, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &RecvStatement{Chan: ch}, nil
+	case "close":
+		p.word()
+		ch, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &CloseStatement{Chan: ch}, nil
+	case "call":
+		return p.parseCallLike(false)
+	case "spawn":
+		return p.parseCallLike(true)
+	case "select":
+		return p.parseSelect()
+	case "if":
+		return p.parseIf()
+	case "ifFor":
+		return p.parseIfFor()
+	case "letsync":
+		return p.parseLetSync()
+	case "lock":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &SyncMutexLock{Name: name}, nil
+	case "unlock":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &SyncMutexUnlock{Name: name}, nil
+	case "rlock":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &SyncRWMutexRLock{Name: name}, nil
+	case "runlock":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &SyncRWMutexRUnlock{Name: name}, nil
+	case "letmem":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &NewMem{Name: ident(name)}, nil
+	case "read":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &MemRead{Name: name}, nil
+	case "write":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &MemWrite{Name: name}, nil
+	case "wgadd":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectByte(','); err != nil {
+			return nil, err
+		}
+		deltaWord, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		delta, err := strconv.Atoi(deltaWord)
+		if err != nil {
+			return nil, p.errorf("invalid wgadd delta %q: %v", deltaWord, err)
+		}
+		return &SyncWGAdd{Name: name, Delta: delta}, nil
+	case "wgdone":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &SyncWGDone{Name: name}, nil
+	case "wgwait":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &SyncWGWait{Name: name}, nil
+	case "oncedo":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		fn, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &SyncOnceDo{Name: name, Fn: fn}, nil
+	case "condwait":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &SyncCondWait{Name: name}, nil
+	case "condsignal":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &SyncCondSignal{Name: name}, nil
+	case "condbroadcast":
+		p.word()
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		return &SyncCondBroadcast{Name: name}, nil
+	case "tau":
+		p.word()
+		return &TauStatement{}, nil
+	default:
+		return nil, p.errorf("unexpected token %q", kw)
+	}
+}
+
+// parseNewChan parses "let x = newchan c, N".
+func (p *parser) parseNewChan() (Statement, error) {
+	p.word() // "let"
+	name, err := p.word()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectByte('='); err != nil {
+		return nil, err
+	}
+	if err := p.expectWord("newchan"); err != nil {
+		return nil, err
+	}
+	chanName, err := p.word()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectByte(','); err != nil {
+		return nil, err
+	}
+	szWord, err := p.word()
+	if err != nil {
+		return nil, err
+	}
+	sz, err := strconv.ParseInt(szWord, 10, 64)
+	if err != nil {
+		return nil, p.errorf("invalid channel size %q: %v", szWord, err)
+	}
+	return &NewChanStatement{Name: ident(name), Chan: chanName, Size: sz}, nil
+}
+
+// parseCallLike parses "call f(a, b)" or "spawn f(a, b)".
+func (p *parser) parseCallLike(spawn bool) (Statement, error) {
+	p.word() // "call" or "spawn"
+	name, err := p.word()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectByte('('); err != nil {
+		return nil, err
+	}
+	args, err := p.parseWordList()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectByte(')'); err != nil {
+		return nil, err
+	}
+	params := make([]*Parameter, len(args))
+	for i, a := range args {
+		v := ident(a)
+		params[i] = &Parameter{Caller: v, Callee: v}
+	}
+	if spawn {
+		return &SpawnStatement{Name: name, Params: params}, nil
+	}
+	return &CallStatement{Name: name, Params: params}, nil
+}
+
+// parseIf parses "if S1; S2; ... else T1; T2; ... endif".
+func (p *parser) parseIf() (Statement, error) {
+	p.word() // "if"
+	then, err := p.parseStmtsUntil("else")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectWord("else"); err != nil {
+		return nil, err
+	}
+	els, err := p.parseStmtsUntil("endif")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectWord("endif"); err != nil {
+		return nil, err
+	}
+	return &IfStatement{Then: then, Else: els}, nil
+}
+
+// parseIfFor parses "ifFor (int cond) then S1; ... else T1; ... endif".
+func (p *parser) parseIfFor() (Statement, error) {
+	p.word() // "ifFor"
+	if err := p.expectByte('('); err != nil {
+		return nil, err
+	}
+	if err := p.expectWord("int"); err != nil {
+		return nil, err
+	}
+	cond, err := p.word()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectByte(')'); err != nil {
+		return nil, err
+	}
+	if err := p.expectWord("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.parseStmtsUntil("else")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectWord("else"); err != nil {
+		return nil, err
+	}
+	els, err := p.parseStmtsUntil("endif")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectWord("endif"); err != nil {
+		return nil, err
+	}
+	return &IfForStatement{ForCond: cond, Then: then, Else: els}, nil
+}
+
+// parseSelect parses "select case S1; S2; case T1; endselect".
+func (p *parser) parseSelect() (Statement, error) {
+	p.word() // "select"
+	var cases [][]Statement
+	for {
+		w, err := p.peekWord()
+		if err != nil {
+			return nil, err
+		}
+		if w == "endselect" {
+			p.word()
+			break
+		}
+		if err := p.expectWord("case"); err != nil {
+			return nil, err
+		}
+		c, err := p.parseStmtsUntil("case", "endselect")
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	return &SelectStatement{Cases: cases}, nil
+}
+
+// parseLetSync parses "letsync g mutex", "letsync g rwmutex",
+// "letsync g waitgroup", "letsync g once", or "letsync g cond".
+func (p *parser) parseLetSync() (Statement, error) {
+	p.word() // "letsync"
+	name, err := p.word()
+	if err != nil {
+		return nil, err
+	}
+	kind, err := p.word()
+	if err != nil {
+		return nil, err
+	}
+	v := ident(name)
+	switch kind {
+	case "mutex":
+		return &NewSyncMutex{Name: v}, nil
+	case "rwmutex":
+		return &NewSyncRWMutex{Name: v}, nil
+	case "waitgroup":
+		return &NewSyncWaitGroup{Name: v}, nil
+	case "once":
+		return &NewSyncOnce{Name: v}, nil
+	case "cond":
+		return &NewSyncCond{Name: v}, nil
+	default:
+		return nil, p.errorf("unknown letsync kind %q", kind)
+	}
+}
@@ -0,0 +1,194 @@
+package migo
+
+import (
+	"strings"
+	"testing"
+)
+
+// buildExoticProgram returns a Program exercising realistic SSA-derived
+// names containing characters nameFilter used to strip, such as the ones
+// CallStatement.Name and Function.Name take from x/tools/go/ssa:
+// "(*pkg.T).Foo" for a method value and "\"pkg/path\".main" for a
+// package-qualified function.
+func buildExoticProgram() *Program {
+	prog := NewProgram()
+
+	main := NewFunction(`"pkg/path".main`, noPosition)
+	main.AddStmts(
+		&CallStatement{Name: "(*pkg.T).Foo"},
+		&SpawnStatement{Name: "(*pkg.U).Bar"},
+		&NewChanStatement{Name: ident("x"), Chan: "(*pkg.T).ch", Size: 0},
+		&SendStatement{Chan: "(*pkg.T).ch"},
+		&RecvStatement{Chan: "(*pkg.T).ch"},
+		&CloseStatement{Chan: "(*pkg.T).ch"},
+	)
+	prog.AddFunction(main)
+
+	foo := NewFunction("(*pkg.T).Foo", noPosition)
+	foo.AddStmts(&TauStatement{})
+	prog.AddFunction(foo)
+
+	bar := NewFunction("(*pkg.U).Bar", noPosition)
+	bar.AddStmts(&TauStatement{})
+	prog.AddFunction(bar)
+
+	return prog
+}
+
+// TestParseRoundTrip asserts that Parse(prog.String()) reconstructs the
+// same function and statement names as prog, including names that contain
+// bytes nameFilter used to strip ('(', ')', '*', '"') before quoteName was
+// introduced to escape them instead.
+func TestParseRoundTrip(t *testing.T) {
+	prog := buildExoticProgram()
+
+	reparsed, err := Parse(strings.NewReader(prog.String()))
+	if err != nil {
+		t.Fatalf("Parse(prog.String()): %v", err)
+	}
+
+	main, ok := reparsed.Function(`"pkg/path".main`)
+	if !ok {
+		t.Fatalf(`Function(%q) not found after round-trip`, `"pkg/path".main`)
+	}
+	if len(main.Stmts) != 6 {
+		t.Fatalf("main.Stmts: got %d statements, want 6", len(main.Stmts))
+	}
+
+	call, ok := main.Stmts[0].(*CallStatement)
+	if !ok || call.Name != "(*pkg.T).Foo" {
+		t.Errorf("main.Stmts[0].Name: got %#v, want CallStatement{Name: %q}", main.Stmts[0], "(*pkg.T).Foo")
+	}
+
+	spawn, ok := main.Stmts[1].(*SpawnStatement)
+	if !ok || spawn.Name != "(*pkg.U).Bar" {
+		t.Errorf("main.Stmts[1].Name: got %#v, want SpawnStatement{Name: %q}", main.Stmts[1], "(*pkg.U).Bar")
+	}
+
+	newChan, ok := main.Stmts[2].(*NewChanStatement)
+	if !ok || newChan.Chan != "(*pkg.T).ch" {
+		t.Errorf("main.Stmts[2].Chan: got %#v, want NewChanStatement{Chan: %q}", main.Stmts[2], "(*pkg.T).ch")
+	}
+
+	send, ok := main.Stmts[3].(*SendStatement)
+	if !ok || send.Chan != "(*pkg.T).ch" {
+		t.Errorf("main.Stmts[3].Chan: got %#v, want SendStatement{Chan: %q}", main.Stmts[3], "(*pkg.T).ch")
+	}
+
+	recv, ok := main.Stmts[4].(*RecvStatement)
+	if !ok || recv.Chan != "(*pkg.T).ch" {
+		t.Errorf("main.Stmts[4].Chan: got %#v, want RecvStatement{Chan: %q}", main.Stmts[4], "(*pkg.T).ch")
+	}
+
+	cl, ok := main.Stmts[5].(*CloseStatement)
+	if !ok || cl.Chan != "(*pkg.T).ch" {
+		t.Errorf("main.Stmts[5].Chan: got %#v, want CloseStatement{Chan: %q}", main.Stmts[5], "(*pkg.T).ch")
+	}
+
+	if _, ok := reparsed.Function("(*pkg.T).Foo"); !ok {
+		t.Errorf(`Function(%q) not found after round-trip`, "(*pkg.T).Foo")
+	}
+	if _, ok := reparsed.Function("(*pkg.U).Bar"); !ok {
+		t.Errorf(`Function(%q) not found after round-trip`, "(*pkg.U).Bar")
+	}
+}
+
+// TestParseRoundTripDistinctCallerCallee asserts that Parse resolves a
+// call's Callee from the target Function's own declared Params, rather
+// than duplicating the caller-side name the call site text carries: the
+// grammar only ever prints a parameter's caller-side name at the call
+// ("call callee(a)") and its callee-side name at the def
+// ("def callee(p):"), so the two can and typically do differ.
+func TestParseRoundTripDistinctCallerCallee(t *testing.T) {
+	prog := NewProgram()
+
+	callee := NewFunction("callee", noPosition)
+	callee.AddParams(&Parameter{Caller: ident("p"), Callee: ident("p")})
+	callee.AddStmts(&TauStatement{})
+	prog.AddFunction(callee)
+
+	main := NewFunction("main.main", noPosition)
+	main.AddStmts(&CallStatement{
+		Name:   "callee",
+		Params: []*Parameter{{Caller: ident("a"), Callee: ident("p")}},
+	})
+	prog.AddFunction(main)
+
+	reparsed, err := Parse(strings.NewReader(prog.String()))
+	if err != nil {
+		t.Fatalf("Parse(prog.String()): %v", err)
+	}
+
+	reparsedMain, ok := reparsed.Function("main.main")
+	if !ok {
+		t.Fatalf(`Function("main.main") not found after round-trip`)
+	}
+	call, ok := reparsedMain.Stmts[0].(*CallStatement)
+	if !ok || len(call.Params) != 1 {
+		t.Fatalf("reparsedMain.Stmts[0] = %#v, want a CallStatement with 1 Param", reparsedMain.Stmts[0])
+	}
+	if got := call.Params[0].Caller.Name(); got != "a" {
+		t.Errorf("call.Params[0].Caller = %q, want %q", got, "a")
+	}
+	if got := call.Params[0].Callee.Name(); got != "p" {
+		t.Errorf("call.Params[0].Callee = %q, want %q (the callee's own declared param name, not the caller's)", got, "p")
+	}
+}
+
+// TestParseRoundTripIdempotent asserts that serializing a round-tripped
+// Program reproduces the exact same text, for a mix of statement kinds
+// beyond the call/spawn/newchan names covered by TestParseRoundTrip.
+func TestParseRoundTripIdempotent(t *testing.T) {
+	prog := NewProgram()
+	f := NewFunction("main.main", noPosition)
+	f.AddStmts(
+		&SendStatement{Chan: "c"},
+		&RecvStatement{Chan: "c"},
+		&CloseStatement{Chan: "c"},
+		&NewSyncMutex{Name: ident("m")},
+		&SyncMutexLock{Name: "m"},
+		&SyncMutexUnlock{Name: "m"},
+		&NewSyncRWMutex{Name: ident("rw")},
+		&SyncRWMutexRLock{Name: "rw"},
+		&SyncRWMutexRUnlock{Name: "rw"},
+		&NewSyncWaitGroup{Name: ident("wg")},
+		&SyncWGAdd{Name: "wg", Delta: 2},
+		&SyncWGDone{Name: "wg"},
+		&SyncWGWait{Name: "wg"},
+		&NewSyncOnce{Name: ident("o")},
+		&SyncOnceDo{Name: "o", Fn: "initFn"},
+		&NewSyncCond{Name: ident("cd")},
+		&SyncCondWait{Name: "cd"},
+		&SyncCondSignal{Name: "cd"},
+		&SyncCondBroadcast{Name: "cd"},
+		&NewMem{Name: ident("v")},
+		&MemRead{Name: "v"},
+		&MemWrite{Name: "v"},
+		&IfStatement{
+			Then: []Statement{&TauStatement{}},
+			Else: []Statement{&SendStatement{Chan: "c"}},
+		},
+		&IfForStatement{
+			ForCond: "i",
+			Then:    []Statement{&SendStatement{Chan: "c"}},
+			Else:    []Statement{&TauStatement{}},
+		},
+		&SelectStatement{Cases: [][]Statement{
+			{&SendStatement{Chan: "c"}},
+			{&TauStatement{}},
+		}},
+	)
+	prog.AddFunction(f)
+
+	want := prog.String()
+
+	reparsed, err := Parse(strings.NewReader(want))
+	if err != nil {
+		t.Fatalf("Parse(prog.String()): %v", err)
+	}
+	got := reparsed.String()
+
+	if got != want {
+		t.Errorf("round-trip not idempotent:\n--- original ---\n%s\n--- reparsed ---\n%s", want, got)
+	}
+}
@@ -0,0 +1,126 @@
+package migo
+
+// callEdges returns the names of every Function called or spawned,
+// directly or through a nested IfStatement/IfForStatement/
+// SelectStatement, from the Function named name.
+func (p *Program) callEdges(name string) []string {
+	fn, ok := p.Function(name)
+	if !ok {
+		return nil
+	}
+	var callees []string
+	Inspect(fn, func(n interface{}) bool {
+		switch s := n.(type) {
+		case *CallStatement:
+			callees = append(callees, s.Name)
+		case *SpawnStatement:
+			callees = append(callees, s.Name)
+		}
+		return true
+	})
+	return callees
+}
+
+// SCCs returns the strongly connected components of Program's call
+// graph reachable from root, ordered bottom-up: every Function a
+// component calls outside of itself belongs to an earlier component.
+func (p *Program) SCCs(root *Function) [][]*Function {
+	t := newTarjan(p.callEdges)
+	t.run(root.Name)
+	return t.functions(p)
+}
+
+// AllSCCs returns the strongly connected components of Program's entire
+// call graph, bottom-up, including components unreachable from any
+// single root.
+func (p *Program) AllSCCs() [][]*Function {
+	t := newTarjan(p.callEdges)
+	for _, f := range p.Funcs {
+		t.run(f.Name)
+	}
+	return t.functions(p)
+}
+
+// tarjan computes strongly connected components via Tarjan's algorithm
+// over a graph given by an edges function, visiting one or more roots.
+type tarjan struct {
+	edges   func(string) []string
+	index   map[string]int
+	lowlink map[string]int
+	onStack map[string]bool
+	stack   []string
+	next    int
+	sccs    [][]string
+}
+
+func newTarjan(edges func(string) []string) *tarjan {
+	return &tarjan{
+		edges:   edges,
+		index:   make(map[string]int),
+		lowlink: make(map[string]int),
+		onStack: make(map[string]bool),
+	}
+}
+
+// run visits root if it has not been visited by an earlier call.
+func (t *tarjan) run(root string) {
+	if _, ok := t.index[root]; !ok {
+		t.strongconnect(root)
+	}
+}
+
+func (t *tarjan) strongconnect(v string) {
+	t.index[v] = t.next
+	t.lowlink[v] = t.next
+	t.next++
+	t.stack = append(t.stack, v)
+	t.onStack[v] = true
+
+	for _, w := range t.edges(v) {
+		if _, ok := t.index[w]; !ok {
+			t.strongconnect(w)
+			if t.lowlink[w] < t.lowlink[v] {
+				t.lowlink[v] = t.lowlink[w]
+			}
+		} else if t.onStack[w] {
+			if t.index[w] < t.lowlink[v] {
+				t.lowlink[v] = t.index[w]
+			}
+		}
+	}
+
+	if t.lowlink[v] != t.index[v] {
+		return
+	}
+	var scc []string
+	for {
+		n := len(t.stack) - 1
+		w := t.stack[n]
+		t.stack = t.stack[:n]
+		t.onStack[w] = false
+		scc = append(scc, w)
+		if w == v {
+			break
+		}
+	}
+	t.sccs = append(t.sccs, scc)
+}
+
+// functions resolves every name in t.sccs to its Function in p, dropping
+// names with no matching Function (e.g. a call to a function that was
+// never defined).
+func (t *tarjan) functions(p *Program) [][]*Function {
+	sccs := make([][]*Function, 0, len(t.sccs))
+	for _, names := range t.sccs {
+		funcs := make([]*Function, 0, len(names))
+		for _, name := range names {
+			if f, ok := p.Function(name); ok {
+				funcs = append(funcs, f)
+			}
+		}
+		if len(funcs) > 0 {
+			sccs = append(sccs, funcs)
+		}
+	}
+	return sccs
+}
@@ -0,0 +1,106 @@
+package migo
+
+import (
+	"go/token"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// funcCalling returns a Function named name whose body calls each of
+// calls, registered into prog.
+func funcCalling(prog *Program, name string, calls ...string) *Function {
+	f := NewFunction(name, token.Position{})
+	for _, c := range calls {
+		f.AddStmts(&CallStatement{Name: c})
+	}
+	if len(calls) == 0 {
+		f.AddStmts(&TauStatement{})
+	}
+	prog.AddFunction(f)
+	return f
+}
+
+// sccNames sorts the names within each component, then sorts the
+// components by their first name, so SCC output can be compared without
+// depending on Tarjan's internal visitation order.
+func sccNames(sccs [][]*Function) [][]string {
+	names := make([][]string, len(sccs))
+	for i, scc := range sccs {
+		ns := make([]string, len(scc))
+		for j, f := range scc {
+			ns[j] = f.Name
+		}
+		sort.Strings(ns)
+		names[i] = ns
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i][0] < names[j][0] })
+	return names
+}
+
+func TestSCCsBottomUpOverACycle(t *testing.T) {
+	prog := NewProgram()
+	funcCalling(prog, "main", "a")
+	funcCalling(prog, "a", "b")
+	funcCalling(prog, "b", "a", "leaf")
+	funcCalling(prog, "leaf")
+
+	main, _ := prog.Function("main")
+	sccs := prog.SCCs(main)
+
+	got := sccNames(sccs)
+	want := [][]string{{"a", "b"}, {"leaf"}, {"main"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SCCs() = %v, want %v", got, want)
+	}
+
+	// Bottom-up: every component's callees outside itself must already
+	// have appeared in an earlier component.
+	seen := map[string]bool{}
+	for _, scc := range sccs {
+		names := map[string]bool{}
+		for _, f := range scc {
+			names[f.Name] = true
+		}
+		for _, f := range scc {
+			for _, callee := range prog.callEdges(f.Name) {
+				if names[callee] {
+					continue
+				}
+				if !seen[callee] {
+					t.Errorf("callee %q of %q appears before its own component", callee, f.Name)
+				}
+			}
+		}
+		for n := range names {
+			seen[n] = true
+		}
+	}
+}
+
+func TestSCCsIgnoresCallsToUndefinedFunctions(t *testing.T) {
+	prog := NewProgram()
+	funcCalling(prog, "main", "missing")
+
+	main, _ := prog.Function("main")
+	sccs := prog.SCCs(main)
+
+	got := sccNames(sccs)
+	want := [][]string{{"main"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SCCs() = %v, want %v (call to undefined function dropped)", got, want)
+	}
+}
+
+func TestAllSCCsIncludesUnreachableFunctions(t *testing.T) {
+	prog := NewProgram()
+	funcCalling(prog, "main", "a")
+	funcCalling(prog, "a")
+	funcCalling(prog, "orphan")
+
+	got := sccNames(prog.AllSCCs())
+	want := [][]string{{"a"}, {"main"}, {"orphan"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("AllSCCs() = %v, want %v", got, want)
+	}
+}
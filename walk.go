@@ -0,0 +1,130 @@
+package migo
+
+// Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w.
+type Visitor interface {
+	Visit(node interface{}) (w Visitor)
+}
+
+// Walk traverses a migo syntax tree in depth-first order: it starts by
+// calling v.Visit(node); node must not be nil. If the visitor w returned
+// by v.Visit(node) is not nil, Walk visits each of the children of node
+// with the visitor w.
+//
+// node may be a *Program, *Function, a []Statement, or any of the
+// concrete Statement implementations (e.g. *IfStatement,
+// *SelectStatement).
+func Walk(v Visitor, node interface{}) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, f := range n.Funcs {
+			Walk(v, f)
+		}
+	case *Function:
+		walkStmts(v, n.Stmts)
+	case []Statement:
+		walkStmts(v, n)
+	case *IfStatement:
+		walkStmts(v, n.Then)
+		walkStmts(v, n.Else)
+	case *IfForStatement:
+		walkStmts(v, n.Then)
+		walkStmts(v, n.Else)
+	case *SelectStatement:
+		for _, c := range n.Cases {
+			walkStmts(v, c)
+		}
+	default:
+		// Leaf statements (CallStatement, SendStatement, TauStatement, ...)
+		// have no children to descend into.
+	}
+
+	v.Visit(nil)
+}
+
+// walkStmts calls Walk(v, s) for each Statement in stmts.
+func walkStmts(v Visitor, stmts []Statement) {
+	for _, s := range stmts {
+		Walk(v, s)
+	}
+}
+
+// RewriteStmts rebuilds stmts bottom-up: it first descends into the
+// nested statement lists of every IfStatement, IfForStatement and
+// SelectStatement in stmts (in place, via RewriteStmts), then calls
+// rewrite with the result and returns what rewrite returns.
+//
+// Passes that rebuild a migo syntax tree, such as devirt and deadstmt,
+// use this for the part of their traversal that is identical across
+// passes - finding a node's nested statement lists - the same way Walk
+// and Inspect do for read-only traversal, instead of duplicating it.
+func RewriteStmts(stmts []Statement, rewrite func([]Statement) []Statement) []Statement {
+	for _, s := range stmts {
+		switch n := s.(type) {
+		case *IfStatement:
+			n.Then = RewriteStmts(n.Then, rewrite)
+			n.Else = RewriteStmts(n.Else, rewrite)
+		case *IfForStatement:
+			n.Then = RewriteStmts(n.Then, rewrite)
+			n.Else = RewriteStmts(n.Else, rewrite)
+		case *SelectStatement:
+			for i, c := range n.Cases {
+				n.Cases[i] = RewriteStmts(c, rewrite)
+			}
+		}
+	}
+	return rewrite(stmts)
+}
+
+// RenderStmts captures String() for every statement in stmts, up front.
+//
+// Passes that rebuild a statement list by mutating statements in place
+// through pointers shared with an earlier snapshot, such as RewriteStmts
+// descending into an IfStatement's Then/Else, must call this before that
+// mutation happens: comparing the live, already-mutated slice against
+// itself afterwards would always report no change.
+func RenderStmts(stmts []Statement) []string {
+	rendered := make([]string, len(stmts))
+	for i, stmt := range stmts {
+		rendered[i] = stmt.String()
+	}
+	return rendered
+}
+
+// EqualRendered reports whether rendered, a snapshot from RenderStmts,
+// still matches stmts' current String() output.
+func EqualRendered(rendered []string, stmts []Statement) bool {
+	if len(rendered) != len(stmts) {
+		return false
+	}
+	for i, s := range stmts {
+		if rendered[i] != s.String() {
+			return false
+		}
+	}
+	return true
+}
+
+// inspector adapts a function to the Visitor interface so Inspect can
+// reuse Walk.
+type inspector func(node interface{}) bool
+
+func (f inspector) Visit(node interface{}) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a migo syntax tree in depth-first order: it starts
+// by calling f(node); node must not be nil. If f returns true, Inspect
+// invokes f recursively for each of the children of node, followed by a
+// call of f(nil).
+func Inspect(node interface{}, f func(node interface{}) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,129 @@
+package migo
+
+import (
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+// collector records the concrete type of every non-nil node Walk/Inspect
+// visits, in visitation order, so tests can assert on traversal shape
+// without depending on String() formatting.
+type collector []string
+
+func (c *collector) Visit(node interface{}) Visitor {
+	if node == nil {
+		return nil
+	}
+	*c = append(*c, reflect.TypeOf(node).String())
+	return c
+}
+
+func TestWalkDescendsIntoNestedStatementLists(t *testing.T) {
+	prog := NewProgram()
+	f := NewFunction("main.main", token.Position{})
+	f.AddStmts(
+		&IfStatement{
+			Then: []Statement{&TauStatement{}},
+			Else: []Statement{&SendStatement{Chan: "c"}},
+		},
+		&IfForStatement{
+			ForCond: "i",
+			Then:    []Statement{&RecvStatement{Chan: "c"}},
+			Else:    []Statement{&TauStatement{}},
+		},
+		&SelectStatement{Cases: [][]Statement{
+			{&SendStatement{Chan: "c"}},
+			{&CloseStatement{Chan: "c"}},
+		}},
+	)
+	prog.AddFunction(f)
+
+	var c collector
+	Walk(&c, prog)
+
+	want := []string{
+		"*migo.Program",
+		"*migo.Function",
+		"*migo.IfStatement",
+		"*migo.TauStatement",
+		"*migo.SendStatement",
+		"*migo.IfForStatement",
+		"*migo.RecvStatement",
+		"*migo.TauStatement",
+		"*migo.SelectStatement",
+		"*migo.SendStatement",
+		"*migo.CloseStatement",
+	}
+	if !reflect.DeepEqual([]string(c), want) {
+		t.Errorf("Walk visited %v, want %v", []string(c), want)
+	}
+}
+
+func TestInspectStopsWhenCallbackReturnsFalse(t *testing.T) {
+	prog := NewProgram()
+	f := NewFunction("main.main", token.Position{})
+	f.AddStmts(
+		&IfStatement{
+			Then: []Statement{&TauStatement{}},
+			Else: []Statement{&SendStatement{Chan: "c"}},
+		},
+		&CloseStatement{Chan: "c"},
+	)
+	prog.AddFunction(f)
+
+	var visited []string
+	Inspect(prog, func(n interface{}) bool {
+		if n == nil {
+			return true
+		}
+		visited = append(visited, reflect.TypeOf(n).String())
+		// Refuse to descend into IfStatement's children.
+		_, isIf := n.(*IfStatement)
+		return !isIf
+	})
+
+	want := []string{"*migo.Program", "*migo.Function", "*migo.IfStatement", "*migo.CloseStatement"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("Inspect visited %v, want %v", visited, want)
+	}
+}
+
+func TestRewriteStmtsDescendsBeforeRewriting(t *testing.T) {
+	stmts := []Statement{
+		&IfStatement{
+			Then: []Statement{&TauStatement{}, &TauStatement{}},
+			Else: []Statement{&SendStatement{Chan: "c"}},
+		},
+		&TauStatement{},
+	}
+
+	// Drop TauStatements, innermost first, then from the rewritten
+	// top-level slice.
+	dropTau := func(in []Statement) []Statement {
+		var out []Statement
+		for _, s := range in {
+			if _, ok := s.(*TauStatement); ok {
+				continue
+			}
+			out = append(out, s)
+		}
+		return out
+	}
+
+	got := RewriteStmts(stmts, dropTau)
+
+	if len(got) != 1 {
+		t.Fatalf("RewriteStmts: got %d top-level statements, want 1", len(got))
+	}
+	ifStmt, ok := got[0].(*IfStatement)
+	if !ok {
+		t.Fatalf("RewriteStmts()[0] = %#v, want *IfStatement", got[0])
+	}
+	if len(ifStmt.Then) != 0 {
+		t.Errorf("ifStmt.Then: got %v, want empty (taus dropped)", ifStmt.Then)
+	}
+	if len(ifStmt.Else) != 1 {
+		t.Errorf("ifStmt.Else: got %v, want 1 statement", ifStmt.Else)
+	}
+}